@@ -2,14 +2,18 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/spf13/cobra"
+
+	"github.com/qw4990/plan-change-capturer/fingerprint"
 )
 
 type exportOpt struct {
@@ -71,19 +75,73 @@ func runExportStmtSummary(opt *exportOpt) error {
 	return exportQueriesFromStmtSummary(db, opt.queryFile)
 }
 
+// fingerprintGroup tracks every statement that normalizes to the same
+// fingerprint, so exportQueriesFromStmtSummary only needs to emit one
+// representative query per group. FirstSeen/LastSeen/Count are carried over
+// and merged across export runs, so they reflect the statement's true
+// history rather than just this run's.
+type fingerprintGroup struct {
+	Count       int       `json:"count"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Sample      string    `json:"sample"`
+	Digests     []string  `json:"digests,omitempty"`
+	PlanDigests []string  `json:"plan_digests,omitempty"`
+}
+
 func exportQueriesFromStmtSummary(db *tidbHandler, dstFile string) error {
-	rows, err := db.db.Query("SELECT QUERY_SAMPLE_TEXT FROM information_schema.cluster_statements_summary_history WHERE lower(QUERY_SAMPLE_TEXT) LIKE '%select%'")
+	rows, err := db.db.Query(`SELECT DIGEST, DIGEST_TEXT, PLAN_DIGEST, QUERY_SAMPLE_TEXT, FIRST_SEEN, LAST_SEEN, EXEC_COUNT
+		FROM information_schema.cluster_statements_summary_history WHERE STMT_TYPE = 'Select'`)
 	if err != nil {
 		return fmt.Errorf("select queries from information_schema.cluster_statements_summary_history error: %v", err)
 	}
 	defer rows.Close()
-	var queries []string
+
+	fingerprintsFile := dstFile + ".fingerprints.json"
+	groups, err := loadFingerprintGroups(fingerprintsFile)
+	if err != nil {
+		return fmt.Errorf("load existing fingerprint groups error: %v", err)
+	}
+
+	var order []string
+	seenThisRun := make(map[string]bool)
+	var execCountThisRun int64
 	for rows.Next() {
-		var query string
-		if err := rows.Scan(&query); err != nil {
+		var digest, digestText, planDigest, query string
+		var firstSeen, lastSeen time.Time
+		var execCount int64
+		if err := rows.Scan(&digest, &digestText, &planDigest, &query, &firstSeen, &lastSeen, &execCount); err != nil {
 			return fmt.Errorf("scan result error: %v", err)
 		}
-		queries = append(queries, query)
+		execCountThisRun += execCount
+
+		// Group on the normalized fingerprint rather than TiDB's own DIGEST:
+		// DIGEST only dedupes statements that are byte-identical after TiDB's
+		// own normalization, while fingerprint.Hash also collapses
+		// differences such as canonicalized IN-lists.
+		key := fingerprint.Hash(query)
+		group, ok := groups[key]
+		if !ok {
+			group = &fingerprintGroup{Sample: query}
+			groups[key] = group
+		}
+		group.Count += int(execCount)
+		if group.FirstSeen.IsZero() || firstSeen.Before(group.FirstSeen) {
+			group.FirstSeen = firstSeen
+		}
+		if lastSeen.After(group.LastSeen) {
+			group.LastSeen = lastSeen
+		}
+		group.Digests = appendUnique(group.Digests, digest)
+		group.PlanDigests = appendUnique(group.PlanDigests, planDigest)
+
+		if !seenThisRun[key] {
+			seenThisRun[key] = true
+			order = append(order, key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate result error: %v", err)
 	}
 
 	file, err := os.OpenFile(dstFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
@@ -92,18 +150,58 @@ func exportQueriesFromStmtSummary(db *tidbHandler, dstFile string) error {
 	}
 	defer file.Close()
 	buf := bufio.NewWriter(file)
-	for _, q := range queries {
-		if _, err := buf.Write([]byte(q + ";\n")); err != nil {
+	for _, key := range order {
+		if _, err := buf.Write([]byte(groups[key].Sample + ";\n")); err != nil {
 			return err
 		}
 	}
 	if err := buf.Flush(); err != nil {
 		return err
 	}
-	fmt.Printf("export queries from statement_summary into %v successfully\n", dstFile)
+
+	data, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal fingerprint groups error: %v", err)
+	}
+	if err := ioutil.WriteFile(fingerprintsFile, data, 0666); err != nil {
+		return err
+	}
+
+	fmt.Printf("export %v query group(s) (%v statement(s) observed this run) from statement_summary into %v and %v\n",
+		len(order), execCountThisRun, dstFile, fingerprintsFile)
 	return nil
 }
 
+// loadFingerprintGroups reads any fingerprint groups persisted by a previous
+// export run, so their Count/FirstSeen/LastSeen can be merged with this
+// run's rather than being overwritten.
+func loadFingerprintGroups(path string) (map[string]*fingerprintGroup, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]*fingerprintGroup), nil
+		}
+		return nil, err
+	}
+	groups := make(map[string]*fingerprintGroup)
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("unmarshal %v error: %v", path, err)
+	}
+	return groups, nil
+}
+
+func appendUnique(list []string, val string) []string {
+	if val == "" {
+		return list
+	}
+	for _, v := range list {
+		if v == val {
+			return list
+		}
+	}
+	return append(list, val)
+}
+
 func runExportSchemaStats(opt *exportOpt) error {
 	if opt.dir == "" {
 		return fmt.Errorf("please specific a destination directory")
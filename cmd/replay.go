@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/spf13/cobra"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+)
+
+type replayOpt struct {
+	schemaStatsDir string
+	queryFile      string
+	before         tidbAccessOptions
+	after          tidbAccessOptions
+	rowTolerance   float64
+}
+
+func newReplayCmd() *cobra.Command {
+	var opt replayOpt
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "re-execute captured queries against two TiDB clusters and diff their plans",
+		Long: `replay restores the schemas and stats exported by 'pcc export --mode=schema_stats'
+into a "before" and an "after" TiDB, runs every query from the query file against both,
+and reports any query whose plan changed between the two`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReplay(&opt)
+		},
+	}
+	cmd.Flags().StringVar(&opt.schemaStatsDir, "schema-stats-dir", "", "directory produced by 'pcc export --mode=schema_stats'")
+	cmd.Flags().StringVar(&opt.queryFile, "query-file", "", "file containing one query per line to replay")
+	cmd.Flags().StringVar(&opt.before.addr, "before-addr", "127.0.0.1", "address of the 'before' TiDB")
+	cmd.Flags().StringVar(&opt.before.port, "before-port", "4000", "port of the 'before' TiDB")
+	cmd.Flags().StringVar(&opt.before.statusPort, "before-status-port", "10080", "status port of the 'before' TiDB")
+	cmd.Flags().StringVar(&opt.before.user, "before-user", "", "user name to access the 'before' TiDB")
+	cmd.Flags().StringVar(&opt.before.password, "before-password", "", "password to access the 'before' TiDB")
+	cmd.Flags().StringVar(&opt.after.addr, "after-addr", "127.0.0.1", "address of the 'after' TiDB")
+	cmd.Flags().StringVar(&opt.after.port, "after-port", "4000", "port of the 'after' TiDB")
+	cmd.Flags().StringVar(&opt.after.statusPort, "after-status-port", "10080", "status port of the 'after' TiDB")
+	cmd.Flags().StringVar(&opt.after.user, "after-user", "", "user name to access the 'after' TiDB")
+	cmd.Flags().StringVar(&opt.after.password, "after-password", "", "password to access the 'after' TiDB")
+	cmd.Flags().Float64Var(&opt.rowTolerance, "row-tolerance", 0, "also flag a regression when an operator's estimated row count diverges by more than this ratio (e.g. 2 allows up to 2x); 0 disables the check")
+	return cmd
+}
+
+func runReplay(opt *replayOpt) error {
+	if opt.schemaStatsDir == "" {
+		return fmt.Errorf("please specify a schema-stats directory")
+	}
+	if opt.queryFile == "" {
+		return fmt.Errorf("please specify a query file")
+	}
+	queries, err := readQueries(opt.queryFile)
+	if err != nil {
+		return fmt.Errorf("read query file error: %v", err)
+	}
+
+	beforeDB, err := connectDB(opt.before, "mysql")
+	if err != nil {
+		return fmt.Errorf("connect to 'before' DB error: %v", err)
+	}
+	afterDB, err := connectDB(opt.after, "mysql")
+	if err != nil {
+		return fmt.Errorf("connect to 'after' DB error: %v", err)
+	}
+	if err := restoreSchemaStats(beforeDB, opt.schemaStatsDir); err != nil {
+		return fmt.Errorf("restore schema/stats on 'before' DB error: %v", err)
+	}
+	if err := restoreSchemaStats(afterDB, opt.schemaStatsDir); err != nil {
+		return fmt.Errorf("restore schema/stats on 'after' DB error: %v", err)
+	}
+
+	beforeVer, err := beforeDB.getVersion()
+	if err != nil {
+		return fmt.Errorf("get 'before' DB version error: %v", err)
+	}
+	afterVer, err := afterDB.getVersion()
+	if err != nil {
+		return fmt.Errorf("get 'after' DB version error: %v", err)
+	}
+
+	var regressed int
+	for _, query := range queries {
+		beforePlan, err := explainStatement(beforeDB, beforeVer, query)
+		if err != nil {
+			fmt.Printf("explain %q on 'before' DB error: %v\n", query, err)
+			continue
+		}
+		afterPlan, err := explainStatement(afterDB, afterVer, query)
+		if err != nil {
+			fmt.Printf("explain %q on 'after' DB error: %v\n", query, err)
+			continue
+		}
+		diffs := plan.CompareWithOptions(beforePlan, afterPlan, plan.CompareOptions{EstRowTolerance: opt.rowTolerance})
+		if len(diffs) > 0 {
+			regressed++
+			fmt.Printf("[REGRESSION] %v\n", query)
+			for _, d := range diffs {
+				fmt.Printf("  - %v\n", d.Reason)
+			}
+		} else {
+			fmt.Printf("[OK] %v\n", query)
+		}
+	}
+	fmt.Printf("replay finished: %v/%v quer(ies) regressed\n", regressed, len(queries))
+	return nil
+}
+
+func readQueries(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var queries []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSuffix(line, ";")
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		queries = append(queries, line)
+	}
+	return queries, nil
+}
+
+// restoreSchemaStats loads every `<db>.<table>.schema.sql` / `<db>.<table>.stats.json`
+// pair written by exportTableSchemas/exportTableStats into db.
+func restoreSchemaStats(db *tidbHandler, dir string) error {
+	schemaFiles, err := filepath.Glob(filepath.Join(dir, "*.schema.sql"))
+	if err != nil {
+		return err
+	}
+	for _, schemaFile := range schemaFiles {
+		dbName, _, ok := parseSchemaFileName(schemaFile)
+		if !ok {
+			continue
+		}
+		if _, err := db.db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%v`", dbName)); err != nil {
+			return fmt.Errorf("create database %v error: %v", dbName, err)
+		}
+		createSQL, err := ioutil.ReadFile(schemaFile)
+		if err != nil {
+			return fmt.Errorf("read schema file %v error: %v", schemaFile, err)
+		}
+		// Fully qualify the table name instead of relying on a preceding
+		// `USE` sticking: database/sql may hand separate Exec calls to
+		// different pooled connections, so a session-scoped `USE` is not
+		// guaranteed to still be in effect here.
+		qualified := qualifyCreateTableSQL(string(createSQL), dbName)
+		if _, err := db.db.Exec(qualified); err != nil {
+			return fmt.Errorf("exec schema from %v error: %v", schemaFile, err)
+		}
+
+		statsFile := strings.TrimSuffix(schemaFile, ".schema.sql") + ".stats.json"
+		if _, err := os.Stat(statsFile); err != nil {
+			continue // no stats captured for this table
+		}
+		if err := loadTableStats(db, statsFile); err != nil {
+			return fmt.Errorf("load stats from %v error: %v", statsFile, err)
+		}
+	}
+	return nil
+}
+
+// qualifyCreateTableSQL rewrites a `CREATE TABLE `table` (...)` statement,
+// as returned by `SHOW CREATE TABLE`, into `CREATE TABLE `db`.`table` (...)`.
+func qualifyCreateTableSQL(createSQL, dbName string) string {
+	const marker = "CREATE TABLE `"
+	idx := strings.Index(createSQL, marker)
+	if idx == -1 {
+		return createSQL
+	}
+	insertAt := idx + len(marker)
+	return createSQL[:insertAt] + dbName + "`.`" + createSQL[insertAt:]
+}
+
+func parseSchemaFileName(schemaFile string) (dbName, table string, ok bool) {
+	base := strings.TrimSuffix(filepath.Base(schemaFile), ".schema.sql")
+	parts := strings.SplitN(base, ".", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// loadTableStats is the counterpart of exportTableStats: it POSTs a
+// previously-dumped stats file to the `/stats/load` endpoint.
+func loadTableStats(db *tidbHandler, statsFile string) error {
+	data, err := ioutil.ReadFile(statsFile)
+	if err != nil {
+		return err
+	}
+	addr := fmt.Sprintf("http://%v:%v/stats/load", db.opt.addr, db.opt.statusPort)
+	resp, err := http.Post(addr, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("request URL: %v error: %v", addr, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read data from URL: %v response error: %v", addr, err)
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("request URL: %v server error: %v", addr, string(body))
+	}
+	return nil
+}
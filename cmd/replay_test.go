@@ -0,0 +1,38 @@
+package cmd
+
+import "testing"
+
+func TestQualifyCreateTableSQL(t *testing.T) {
+	in := "CREATE TABLE `t1` (\n  `a` int(11) DEFAULT NULL\n) ENGINE=InnoDB"
+	want := "CREATE TABLE `db1`.`t1` (\n  `a` int(11) DEFAULT NULL\n) ENGINE=InnoDB"
+	if got := qualifyCreateTableSQL(in, "db1"); got != want {
+		t.Errorf("qualifyCreateTableSQL() = %q, want %q", got, want)
+	}
+}
+
+func TestQualifyCreateTableSQLNoMatch(t *testing.T) {
+	in := "not a create table statement"
+	if got := qualifyCreateTableSQL(in, "db1"); got != in {
+		t.Errorf("qualifyCreateTableSQL() = %q, want unchanged %q", got, in)
+	}
+}
+
+func TestParseSchemaFileName(t *testing.T) {
+	cases := []struct {
+		path      string
+		wantDB    string
+		wantTable string
+		wantOK    bool
+	}{
+		{"/tmp/dump/db1.t1.schema.sql", "db1", "t1", true},
+		{"/tmp/dump/db1.t1.t2.schema.sql", "db1", "t1.t2", true},
+		{"/tmp/dump/malformed.schema.sql", "", "", false},
+	}
+	for _, c := range cases {
+		db, table, ok := parseSchemaFileName(c.path)
+		if db != c.wantDB || table != c.wantTable || ok != c.wantOK {
+			t.Errorf("parseSchemaFileName(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				c.path, db, table, ok, c.wantDB, c.wantTable, c.wantOK)
+		}
+	}
+}
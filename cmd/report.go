@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/qw4990/plan-change-capturer/store"
+)
+
+type reportOpt struct {
+	storeDriver string
+	storeDSN    string
+	format      string
+	outFile     string
+}
+
+func newReportCmd() *cobra.Command {
+	var opt reportOpt
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "render recorded plan-change events as HTML or JSON",
+		Long:  `report reads every plan-change event recorded by watch and renders them into a single HTML or JSON report`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opt.format = strings.ToLower(opt.format)
+			return runReport(&opt)
+		},
+	}
+	cmd.Flags().StringVar(&opt.storeDriver, "store-driver", "sqlite3", "plan history store backend (sqlite3, mysql, postgres)")
+	cmd.Flags().StringVar(&opt.storeDSN, "store-dsn", "pcc.db", "data source name for the plan history store")
+	cmd.Flags().StringVar(&opt.format, "format", "html", "output format (html, json)")
+	cmd.Flags().StringVar(&opt.outFile, "out", "report.html", "destination file for the report")
+	return cmd
+}
+
+func runReport(opt *reportOpt) error {
+	st, err := store.Open(store.Driver(opt.storeDriver), opt.storeDSN)
+	if err != nil {
+		return fmt.Errorf("open plan history store error: %v", err)
+	}
+	defer st.Close()
+	events, err := st.ChangeEvents()
+	if err != nil {
+		return fmt.Errorf("load plan change events error: %v", err)
+	}
+	var data []byte
+	switch opt.format {
+	case "json":
+		data, err = json.MarshalIndent(events, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal plan change events error: %v", err)
+		}
+	case "html":
+		data, err = renderHTMLReport(events)
+		if err != nil {
+			return fmt.Errorf("render html report error: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown report format %v", opt.format)
+	}
+	if err := ioutil.WriteFile(opt.outFile, data, 0666); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %v plan-change event(s) into %v\n", len(events), opt.outFile)
+	return nil
+}
+
+var reportHTMLTmpl = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Plan Change Report</title></head>
+<body>
+<h1>Plan Change Report</h1>
+<p>{{len .}} plan change(s) detected</p>
+{{range .}}
+<hr>
+<h3>{{.Fingerprint}}</h3>
+<p>Detected at: {{.DetectedAt}}</p>
+<p>Reason: {{.Reason}}</p>
+<pre>before:
+{{.OldPlan.Format}}
+after:
+{{.NewPlan.Format}}</pre>
+{{end}}
+</body>
+</html>
+`))
+
+func renderHTMLReport(events []store.PlanChangeEvent) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := reportHTMLTmpl.Execute(buf, events); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/spf13/cobra"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+	"github.com/qw4990/plan-change-capturer/store"
+)
+
+type watchOpt struct {
+	db           tidbAccessOptions
+	interval     time.Duration
+	storeDriver  string
+	storeDSN     string
+	rowTolerance float64
+}
+
+func newWatchCmd() *cobra.Command {
+	var opt watchOpt
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "watch a TiDB cluster for plan changes",
+		Long: `watch polls information_schema.cluster_statements_summary_history on an
+interval, compares the plan of each statement against the last plan captured
+for its digest, and records a plan-change event whenever they differ`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWatch(&opt)
+		},
+	}
+	cmd.Flags().StringVar(&opt.db.addr, "addr", "127.0.0.1", "address of the target TiDB")
+	cmd.Flags().StringVar(&opt.db.port, "port", "4000", "port of the target TiDB")
+	cmd.Flags().StringVar(&opt.db.statusPort, "status-port", "10080", "status port of the target TiDB")
+	cmd.Flags().StringVar(&opt.db.user, "user", "", "user name to access the target TiDB")
+	cmd.Flags().StringVar(&opt.db.password, "password", "", "password to access the target TiDB")
+	cmd.Flags().DurationVar(&opt.interval, "interval", time.Minute, "how often to poll for new statements")
+	cmd.Flags().StringVar(&opt.storeDriver, "store-driver", "sqlite3", "plan history store backend (sqlite3, mysql, postgres)")
+	cmd.Flags().StringVar(&opt.storeDSN, "store-dsn", "pcc.db", "data source name for the plan history store")
+	cmd.Flags().Float64Var(&opt.rowTolerance, "row-tolerance", 0, "also flag a plan change when an operator's estimated row count diverges by more than this ratio (e.g. 2 allows up to 2x); 0 disables the check")
+	return cmd
+}
+
+func runWatch(opt *watchOpt) error {
+	db, err := connectDB(opt.db, "information_schema")
+	if err != nil {
+		return fmt.Errorf("connect to DB error: %v", err)
+	}
+	st, err := store.Open(store.Driver(opt.storeDriver), opt.storeDSN)
+	if err != nil {
+		return fmt.Errorf("open plan history store error: %v", err)
+	}
+	defer st.Close()
+
+	fmt.Printf("watching %v:%v for plan changes every %v\n", opt.db.addr, opt.db.port, opt.interval)
+	ticker := time.NewTicker(opt.interval)
+	defer ticker.Stop()
+	for {
+		if err := pollPlanChanges(db, st, opt.rowTolerance); err != nil {
+			fmt.Printf("poll plan changes error: %v\n", err)
+		}
+		<-ticker.C
+	}
+}
+
+func pollPlanChanges(db *tidbHandler, st store.Store, rowTolerance float64) error {
+	ver, err := db.getVersion()
+	if err != nil {
+		return fmt.Errorf("get DB version error: %v", err)
+	}
+	rows, err := db.db.Query(`SELECT DIGEST, QUERY_SAMPLE_TEXT FROM information_schema.cluster_statements_summary_history
+		WHERE DIGEST != '' AND STMT_TYPE = 'Select'`)
+	if err != nil {
+		return fmt.Errorf("select from cluster_statements_summary_history error: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var digest, sampleText string
+		if err := rows.Scan(&digest, &sampleText); err != nil {
+			return fmt.Errorf("scan result error: %v", err)
+		}
+		if err := captureAndComparePlan(db, st, ver, digest, sampleText, rowTolerance); err != nil {
+			fmt.Printf("capture plan for digest %v error: %v\n", digest, err)
+		}
+	}
+	return rows.Err()
+}
+
+func captureAndComparePlan(db *tidbHandler, st store.Store, tidbVersion, digest, sampleText string, rowTolerance float64) error {
+	p, err := explainStatement(db, tidbVersion, sampleText)
+	if err != nil {
+		return err
+	}
+	last, ok, err := st.LatestPlan(digest)
+	if err != nil {
+		return fmt.Errorf("load last plan error: %v", err)
+	}
+	if ok {
+		diffs := plan.CompareWithOptions(last.Plan, p, plan.CompareOptions{EstRowTolerance: rowTolerance})
+		if len(diffs) > 0 {
+			reason := formatDiffs(diffs)
+			evt := store.PlanChangeEvent{
+				Fingerprint: digest,
+				OldPlan:     last.Plan,
+				NewPlan:     p,
+				Reason:      reason,
+				DetectedAt:  time.Now(),
+			}
+			if err := st.SaveChangeEvent(evt); err != nil {
+				return fmt.Errorf("save plan change event error: %v", err)
+			}
+			fmt.Printf("plan change detected for digest %v: %v\n", digest, reason)
+		}
+	}
+	return st.SavePlan(store.PlanRecord{
+		Fingerprint: digest,
+		SQL:         sampleText,
+		Plan:        p,
+		Ver:         string(p.Ver),
+		CapturedAt:  time.Now(),
+		TiDBVersion: tidbVersion,
+	})
+}
+
+// formatDiffs joins every plan.Diff's reason into a single human-readable
+// string for storage and display.
+func formatDiffs(diffs []plan.Diff) string {
+	reasons := make([]string, len(diffs))
+	for i, d := range diffs {
+		reasons[i] = d.Reason
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// explainStatement runs `EXPLAIN <sql>` on db and parses the resulting rows
+// into a plan.Plan.
+func explainStatement(db *tidbHandler, tidbVersion, query string) (plan.Plan, error) {
+	rows, err := db.db.Query("EXPLAIN " + query)
+	if err != nil {
+		return plan.Plan{}, fmt.Errorf("explain %v error: %v", query, err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return plan.Plan{}, fmt.Errorf("read explain columns error: %v", err)
+	}
+	var explainRows [][]string
+	for rows.Next() {
+		vals := make([]sql.NullString, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return plan.Plan{}, fmt.Errorf("scan explain row error: %v", err)
+		}
+		row := make([]string, len(cols))
+		for i, v := range vals {
+			row[i] = v.String
+		}
+		explainRows = append(explainRows, row)
+	}
+	return plan.Parse(tidbVersion, query, explainRows)
+}
@@ -0,0 +1,45 @@
+// Package fingerprint normalizes SQL statements so that statements that are
+// structurally identical but differ only in literal values produce the same
+// stable hash.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+var (
+	blockCommentRE = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	lineCommentRE  = regexp.MustCompile(`--[^\n]*`)
+	stringLitRE    = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+	numberLitRE    = regexp.MustCompile(`\b\d+(\.\d+)?\b`)
+	inListRE       = regexp.MustCompile(`(?i)\bin\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	whitespaceRE   = regexp.MustCompile(`\s+`)
+)
+
+// Normalize collapses whitespace, strips comments, replaces literals with
+// `?`, and canonicalizes IN-lists down to a single placeholder, so that
+// `WHERE a = 1` and `WHERE a = 2` normalize to the same text.
+func Normalize(sql string) string {
+	// String literals must be replaced before comments are stripped: a
+	// literal containing "--" or "/*" (e.g. `'2020--12'`) would otherwise be
+	// mistaken for a comment and have the rest of the statement cut off.
+	s := stringLitRE.ReplaceAllString(sql, "?")
+	s = blockCommentRE.ReplaceAllString(s, "")
+	s = lineCommentRE.ReplaceAllString(s, "")
+	s = numberLitRE.ReplaceAllString(s, "?")
+	s = inListRE.ReplaceAllString(s, "in (?)")
+	s = whitespaceRE.ReplaceAllString(s, " ")
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(s, ";")
+	return strings.ToLower(s)
+}
+
+// Hash returns a stable hex-encoded SHA-256 hash of sql's normalized form,
+// suitable for grouping statements that are structurally identical.
+func Hash(sql string) string {
+	sum := sha256.Sum256([]byte(Normalize(sql)))
+	return hex.EncodeToString(sum[:])
+}
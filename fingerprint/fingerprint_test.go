@@ -0,0 +1,41 @@
+package fingerprint
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"SELECT * FROM t WHERE a = 1", "select * from t where a = ?"},
+		{"select  *  from t   where a = 2", "select * from t where a = ?"},
+		{"SELECT * FROM t WHERE a = 1 -- trailing comment", "select * from t where a = ?"},
+		{"SELECT * FROM t /* block comment */ WHERE a = 1", "select * from t where a = ?"},
+		{"SELECT * FROM t WHERE a IN (1, 2, 3)", "select * from t where a in (?)"},
+		// a string literal containing "--" or "/*" must not be mistaken for
+		// the start of a comment and truncate the rest of the statement.
+		{"SELECT * FROM t WHERE note = '2020--12'", "select * from t where note = ?"},
+		{"SELECT * FROM t WHERE note = '/* not a comment */'", "select * from t where note = ?"},
+	}
+	for _, c := range cases {
+		if got := Normalize(c.in); got != c.want {
+			t.Errorf("Normalize(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestHashStableAcrossLiterals(t *testing.T) {
+	a := Hash("SELECT * FROM t WHERE a = 1")
+	b := Hash("SELECT * FROM t WHERE a = 2")
+	if a != b {
+		t.Errorf("Hash differed for statements that only differ in a literal: %v vs %v", a, b)
+	}
+}
+
+func TestHashDiffersForDifferentShapes(t *testing.T) {
+	a := Hash("SELECT * FROM t WHERE a = 1")
+	b := Hash("SELECT * FROM t WHERE b = 1")
+	if a == b {
+		t.Errorf("Hash matched for statements with different shapes")
+	}
+}
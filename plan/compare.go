@@ -0,0 +1,126 @@
+package plan
+
+import "fmt"
+
+// DiffKind identifies what kind of mismatch a Diff record describes.
+type DiffKind int
+
+const (
+	DiffKindSQL DiffKind = iota
+	DiffKindType
+	DiffKindChildren
+	DiffKindTable
+	DiffKindIndex
+	DiffKindEstRow
+)
+
+// Diff describes one point of divergence found while comparing two plans.
+type Diff struct {
+	Path   string // operator ID path, e.g. "HashJoin_1 vs HashJoin_1"
+	Kind   DiffKind
+	LHS    string
+	RHS    string
+	Reason string
+}
+
+// CompareOptions configures CompareWithOptions.
+type CompareOptions struct {
+	// EstRowTolerance is the max allowed ratio (in either direction) between
+	// two operators' estimated row counts before it's reported as a
+	// regression, e.g. 2 allows up to a 2x divergence. <= 1 disables the check.
+	EstRowTolerance float64
+}
+
+// Compare reports whether p1 and p2 have the same plan shape, purely
+// structurally (no cardinality check). It's a thin wrapper around
+// CompareWithOptions for callers that just want a yes/no answer.
+func Compare(p1, p2 Plan) (reason string, same bool) {
+	diffs := CompareWithOptions(p1, p2, CompareOptions{})
+	if len(diffs) == 0 {
+		return "", true
+	}
+	return diffs[0].Reason, false
+}
+
+// CompareWithOptions compares p1 and p2 and returns every divergence found,
+// rather than stopping at the first one. With opts.EstRowTolerance set, it
+// also flags operators whose estimated row counts diverge beyond that ratio,
+// which Compare alone can't detect.
+func CompareWithOptions(p1, p2 Plan, opts CompareOptions) []Diff {
+	if p1.SQL != p2.SQL {
+		return []Diff{{Kind: DiffKindSQL, LHS: p1.SQL, RHS: p2.SQL, Reason: "differentiate SQLs"}}
+	}
+	var diffs []Diff
+	compare(p1.Root, p2.Root, opts, &diffs)
+	return diffs
+}
+
+func compare(op1, op2 Operator, opts CompareOptions, diffs *[]Diff) {
+	path := fmt.Sprintf("%v vs %v", op1.ID(), op2.ID())
+	if op1.Type() != op2.Type() || op1.Task() != op2.Task() {
+		*diffs = append(*diffs, Diff{
+			Path:   path,
+			Kind:   DiffKindType,
+			Reason: fmt.Sprintf("%v and %v have different types", op1.ID(), op2.ID()),
+		})
+		return
+	}
+	c1, c2 := op1.Children(), op2.Children()
+	if len(c1) != len(c2) {
+		*diffs = append(*diffs, Diff{
+			Path:   path,
+			Kind:   DiffKindChildren,
+			Reason: fmt.Sprintf("%v and %v have different children lengths", op1.ID(), op2.ID()),
+		})
+		return
+	}
+	switch op1.Type() {
+	case OpTypeTableScan:
+		t1, t2 := op1.(TableScanOp), op2.(TableScanOp)
+		if t1.Table != t2.Table {
+			*diffs = append(*diffs, Diff{
+				Path: path, Kind: DiffKindTable, LHS: t1.Table, RHS: t2.Table,
+				Reason: fmt.Sprintf("%v:%v, %v:%v", t1.ID(), t1.Table, t2.ID(), t2.Table),
+			})
+		}
+	case OpTypeIndexScan:
+		t1, t2 := op1.(IndexScanOp), op2.(IndexScanOp)
+		if t1.Table != t2.Table || t1.Index != t2.Index {
+			*diffs = append(*diffs, Diff{
+				Path: path, Kind: DiffKindIndex,
+				LHS: fmt.Sprintf("%v.%v", t1.Table, t1.Index), RHS: fmt.Sprintf("%v.%v", t2.Table, t2.Index),
+				Reason: fmt.Sprintf("%v:%v, %v:%v", t1.ID(), t1.Table, t2.ID(), t2.Table),
+			})
+		}
+	}
+	if opts.EstRowTolerance > 1 {
+		if ratio := estRowRatio(op1.EstRow(), op2.EstRow()); ratio > opts.EstRowTolerance {
+			*diffs = append(*diffs, Diff{
+				Path: path, Kind: DiffKindEstRow,
+				LHS: fmt.Sprintf("%v", op1.EstRow()), RHS: fmt.Sprintf("%v", op2.EstRow()),
+				Reason: fmt.Sprintf("%v est rows %v, %v est rows %v, ratio %.2f exceeds tolerance %.2f",
+					op1.ID(), op1.EstRow(), op2.ID(), op2.EstRow(), ratio, opts.EstRowTolerance),
+			})
+		}
+	}
+	for i := range c1 {
+		compare(c1[i], c2[i], opts, diffs)
+	}
+}
+
+// estRowRatio returns the larger-over-smaller ratio between two estimated
+// row counts. Each is clamped to a minimum of 1, matching TiDB's own
+// cardinality-clamping behavior, so a plan with an estimate of 0 doesn't
+// blow up the ratio or divide by zero.
+func estRowRatio(r1, r2 float64) float64 {
+	if r1 < 1 {
+		r1 = 1
+	}
+	if r2 < 1 {
+		r2 = 1
+	}
+	if r1 > r2 {
+		return r1 / r2
+	}
+	return r2 / r1
+}
@@ -0,0 +1,56 @@
+package plan
+
+import "testing"
+
+func tableScan(id, table string, estRow float64) TableScanOp {
+	return TableScanOp{
+		BaseOp: BaseOp{id: id, opType: OpTypeTableScan, estRow: estRow, task: TaskTypeTiKV},
+		Table:  table,
+	}
+}
+
+func TestCompareSameShape(t *testing.T) {
+	p1 := Plan{SQL: "select * from t1", Root: tableScan("TableScan_1", "t1", 100)}
+	p2 := Plan{SQL: "select * from t1", Root: tableScan("TableScan_1", "t1", 100)}
+	if reason, same := Compare(p1, p2); !same {
+		t.Fatalf("expected plans to compare equal, got reason: %v", reason)
+	}
+}
+
+func TestCompareDifferentTable(t *testing.T) {
+	p1 := Plan{SQL: "select * from t1", Root: tableScan("TableScan_1", "t1", 100)}
+	p2 := Plan{SQL: "select * from t1", Root: tableScan("TableScan_1", "t2", 100)}
+	if _, same := Compare(p1, p2); same {
+		t.Fatal("expected plans with different tables to differ")
+	}
+}
+
+func TestCompareWithOptionsEstRowTolerance(t *testing.T) {
+	p1 := Plan{SQL: "select * from t1", Root: tableScan("TableScan_1", "t1", 100)}
+	p2 := Plan{SQL: "select * from t1", Root: tableScan("TableScan_1", "t1", 1e6)}
+
+	// no tolerance set: shape is the same, so no diffs.
+	if diffs := CompareWithOptions(p1, p2, CompareOptions{}); len(diffs) != 0 {
+		t.Errorf("expected no diffs without a tolerance, got %v", diffs)
+	}
+
+	// a tight tolerance (10x) is far below the 1e4x jump, so it's flagged.
+	diffs := CompareWithOptions(p1, p2, CompareOptions{EstRowTolerance: 10})
+	if len(diffs) != 1 || diffs[0].Kind != DiffKindEstRow {
+		t.Fatalf("expected one DiffKindEstRow diff, got %v", diffs)
+	}
+
+	// a tolerance above the actual ratio should not flag anything.
+	if diffs := CompareWithOptions(p1, p2, CompareOptions{EstRowTolerance: 1e6}); len(diffs) != 0 {
+		t.Errorf("expected no diffs with a generous tolerance, got %v", diffs)
+	}
+}
+
+func TestEstRowRatioClampsToOne(t *testing.T) {
+	if ratio := estRowRatio(0, 0.5); ratio != 1 {
+		t.Errorf("estRowRatio(0, 0.5) = %v, want 1 (both clamped to 1)", ratio)
+	}
+	if ratio := estRowRatio(0, 5); ratio != 5 {
+		t.Errorf("estRowRatio(0, 5) = %v, want 5", ratio)
+	}
+}
@@ -0,0 +1,191 @@
+package plan
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// VJSON tags a Plan built from TiDB's `EXPLAIN format='json'` /
+// `EXPLAIN ANALYZE format='json'` output, whose row layout doesn't vary
+// across TiDB versions the way the tabular text format does.
+const VJSON = "json"
+
+// planSchemaVersion is bumped whenever the persisted shape of planJSON
+// changes in a way old readers can't tolerate.
+const planSchemaVersion = 1
+
+// tidbJSONOperator mirrors the tree TiDB itself emits for
+// `EXPLAIN format='json'`.
+type tidbJSONOperator struct {
+	ID           string             `json:"id"`
+	EstRows      string             `json:"estRows"`
+	TaskType     string             `json:"taskType"`
+	AccessObject string             `json:"accessObject"`
+	OperatorInfo string             `json:"operatorInfo"`
+	Children     []tidbJSONOperator `json:"children"`
+}
+
+// ParseJSON parses the JSON tree produced by TiDB's
+// `EXPLAIN format='json'` (or `EXPLAIN ANALYZE format='json'`), which
+// TiDB always emits as an array with a single root operator.
+func ParseJSON(sql string, data []byte) (Plan, error) {
+	var ops []tidbJSONOperator
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return Plan{}, errors.Errorf("parse json explain result error: %v", err)
+	}
+	if len(ops) == 0 {
+		return Plan{}, errors.Errorf("empty json explain result")
+	}
+	root, err := buildOperatorJSON(&ops[0])
+	if err != nil {
+		return Plan{}, err
+	}
+	sql = strings.TrimSpace(sql)
+	sql = strings.TrimSuffix(sql, ";")
+	return Plan{SQL: sql, Ver: VJSON, Root: root}, nil
+}
+
+func buildOperatorJSON(op *tidbJSONOperator) (Operator, error) {
+	estRows, err := strconv.ParseFloat(strings.TrimSpace(op.EstRows), 64)
+	if err != nil {
+		return nil, errors.Errorf("parse estRows of %v error: %v", op.ID, err)
+	}
+	children := make([]Operator, 0, len(op.Children))
+	for i := range op.Children {
+		child, err := buildOperatorJSON(&op.Children[i])
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	base := BaseOp{
+		id:       op.ID,
+		opType:   MatchOpType(op.ID),
+		estRow:   estRows,
+		task:     parseTaskType(op.TaskType),
+		children: children,
+	}
+	return newOperatorV5(base, parseAccessObject(op.AccessObject)), nil
+}
+
+// planJSON is the stable, schema-versioned form a Plan is persisted as,
+// independent of whichever TiDB EXPLAIN format (text or json) produced it.
+type planJSON struct {
+	Schema int           `json:"schema"`
+	SQL    string        `json:"sql"`
+	Ver    PlanVer       `json:"ver"`
+	Root   *operatorJSON `json:"root"`
+}
+
+type operatorJSON struct {
+	ID       string          `json:"id"`
+	OpType   OpType          `json:"op_type"`
+	Task     TaskType        `json:"task"`
+	EstRow   float64         `json:"est_row"`
+	Table    string          `json:"table,omitempty"`
+	Index    string          `json:"index,omitempty"`
+	JoinType JoinType        `json:"join_type,omitempty"`
+	Children []*operatorJSON `json:"children,omitempty"`
+}
+
+// MarshalJSON persists p in the schema-versioned form readable by
+// UnmarshalJSON, regardless of which TiDB EXPLAIN format p was parsed from.
+func (p Plan) MarshalJSON() ([]byte, error) {
+	return json.Marshal(planJSON{
+		Schema: planSchemaVersion,
+		SQL:    p.SQL,
+		Ver:    p.Ver,
+		Root:   toOperatorJSON(p.Root),
+	})
+}
+
+// UnmarshalJSON is the symmetric counterpart of MarshalJSON.
+func (p *Plan) UnmarshalJSON(data []byte) error {
+	var pj planJSON
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return err
+	}
+	if pj.Schema > planSchemaVersion {
+		return errors.Errorf("plan schema version %v is newer than this binary supports (%v)", pj.Schema, planSchemaVersion)
+	}
+	p.SQL = pj.SQL
+	p.Ver = pj.Ver
+	p.Root = fromOperatorJSON(pj.Root)
+	return nil
+}
+
+func toOperatorJSON(op Operator) *operatorJSON {
+	if op == nil {
+		return nil
+	}
+	out := &operatorJSON{
+		ID:     op.ID(),
+		OpType: op.Type(),
+		Task:   op.Task(),
+		EstRow: op.EstRow(),
+	}
+	switch t := op.(type) {
+	case TableScanOp:
+		out.Table = t.Table
+	case IndexScanOp:
+		out.Table = t.Table
+		out.Index = t.Index
+	case HashJoinOp:
+		out.JoinType = t.JoinType
+	case IndexJoinOp:
+		out.JoinType = t.JoinType
+	case MergeJoinOp:
+		out.JoinType = t.JoinType
+	}
+	children := op.Children()
+	if len(children) > 0 {
+		out.Children = make([]*operatorJSON, len(children))
+		for i, c := range children {
+			out.Children[i] = toOperatorJSON(c)
+		}
+	}
+	return out
+}
+
+func fromOperatorJSON(o *operatorJSON) Operator {
+	if o == nil {
+		return nil
+	}
+	children := make([]Operator, 0, len(o.Children))
+	for _, c := range o.Children {
+		children = append(children, fromOperatorJSON(c))
+	}
+	base := BaseOp{id: o.ID, opType: o.OpType, estRow: o.EstRow, task: o.Task, children: children}
+	switch o.OpType {
+	case OpTypeTableScan:
+		return TableScanOp{BaseOp: base, Table: o.Table}
+	case OpTypeIndexScan:
+		return IndexScanOp{BaseOp: base, Table: o.Table, Index: o.Index}
+	case OpTypeHashJoin:
+		return HashJoinOp{BaseOp: base, JoinType: o.JoinType}
+	case OpTypeIndexJoin:
+		return IndexJoinOp{BaseOp: base, JoinType: o.JoinType}
+	case OpTypeMergeJoin:
+		return MergeJoinOp{BaseOp: base, JoinType: o.JoinType}
+	case OpTypeTableReader:
+		return TableReaderOp{BaseOp: base}
+	case OpTypeIndexReader:
+		return IndexReaderOp{BaseOp: base}
+	case OpTypeIndexLookup:
+		return IndexLookupOp{BaseOp: base}
+	case OpTypeSelection:
+		return SelectionOp{BaseOp: base}
+	case OpTypeProjection:
+		return ProjectionOp{BaseOp: base}
+	case OpTypePartitionUnion:
+		return PartitionUnionOp{BaseOp: base}
+	case OpTypeExchangeSender:
+		return ExchangeSenderOp{BaseOp: base}
+	case OpTypeExchangeReceiver:
+		return ExchangeReceiverOp{BaseOp: base}
+	}
+	return base
+}
@@ -0,0 +1,72 @@
+package plan
+
+import "testing"
+
+const sampleExplainJSON = `[
+	{
+		"id": "TableReader_7",
+		"estRows": "3.00",
+		"taskType": "root",
+		"operatorInfo": "data:TableFullScan_6",
+		"children": [
+			{
+				"id": "TableFullScan_6",
+				"estRows": "3.00",
+				"taskType": "cop[tikv]",
+				"accessObject": "table:t1",
+				"operatorInfo": "keep order:false"
+			}
+		]
+	}
+]`
+
+func TestParseJSON(t *testing.T) {
+	p, err := ParseJSON("select * from t1", []byte(sampleExplainJSON))
+	if err != nil {
+		t.Fatalf("ParseJSON error: %v", err)
+	}
+	if p.Ver != VJSON {
+		t.Errorf("Ver = %v, want %v", p.Ver, VJSON)
+	}
+	if p.Root.Type() != OpTypeTableReader {
+		t.Fatalf("root type = %v, want OpTypeTableReader", p.Root.Type())
+	}
+	children := p.Root.Children()
+	if len(children) != 1 {
+		t.Fatalf("root has %v children, want 1", len(children))
+	}
+	scan, ok := children[0].(TableScanOp)
+	if !ok {
+		t.Fatalf("child is %T, want TableScanOp", children[0])
+	}
+	if scan.Table != "t1" {
+		t.Errorf("scan.Table = %q, want t1", scan.Table)
+	}
+	if scan.Task() != TaskTypeTiKV {
+		t.Errorf("scan.Task() = %v, want TaskTypeTiKV", scan.Task())
+	}
+}
+
+func TestPlanJSONRoundTrip(t *testing.T) {
+	p, err := ParseJSON("select * from t1", []byte(sampleExplainJSON))
+	if err != nil {
+		t.Fatalf("ParseJSON error: %v", err)
+	}
+
+	data, err := p.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON error: %v", err)
+	}
+
+	var got Plan
+	if err := got.UnmarshalJSON(data); err != nil {
+		t.Fatalf("UnmarshalJSON error: %v", err)
+	}
+
+	if got.SQL != p.SQL || got.Ver != p.Ver {
+		t.Errorf("round trip mismatch: got SQL=%q Ver=%v, want SQL=%q Ver=%v", got.SQL, got.Ver, p.SQL, p.Ver)
+	}
+	if reason, same := Compare(p, got); !same {
+		t.Errorf("round-tripped plan differs from original: %v", reason)
+	}
+}
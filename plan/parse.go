@@ -29,51 +29,14 @@ func Parse(version, sql string, explainRows [][]string) (Plan, error) {
 		return ParseV3(sql, explainRows)
 	case V4:
 		return ParseV4(sql, explainRows)
+	case V5, V6, V7:
+		// V6 and V7 haven't changed the `EXPLAIN` row format in a way that
+		// matters to us yet, so they ride on the same parser as V5.
+		return ParseV5(sql, explainRows)
 	}
 	return Plan{}, errors.Errorf("unsupported TiDB version %v", ver)
 }
 
-func Compare(p1, p2 Plan) (reason string, same bool) {
-	if p1.SQL != p2.SQL {
-		return "differentiate SQLs", false
-	}
-	return compare(p1.Root, p2.Root)
-}
-
-func compare(op1, op2 Operator) (reason string, same bool) {
-	if op1.Type() != op2.Type() || op1.Task() != op2.Task() {
-		return fmt.Sprintf("%v and %v have different types", op1.ID(), op2.ID()), false
-	}
-	c1, c2 := op1.Children(), op2.Children()
-	if len(c1) != len(c2) {
-		return fmt.Sprintf("%v and %v have different children lengths", op1.ID(), op2.ID()), false
-	}
-	same = true
-	switch op1.Type() {
-	case OpTypeTableScan:
-		t1, t2 := op1.(TableScanOp), op2.(TableScanOp)
-		if t1.Table != t2.Table {
-			same = false
-			reason = fmt.Sprintf("%v:%v, %v:%v", t1.ID(), t1.Table, t2.ID(), t2.Table)
-		}
-	case OpTypeIndexScan:
-		t1, t2 := op1.(IndexScanOp), op2.(IndexScanOp)
-		if t1.Table != t2.Table || t1.Index != t2.Index {
-			same = false
-			reason = fmt.Sprintf("%v:%v, %v:%v", t1.ID(), t1.Table, t2.ID(), t2.Table)
-		}
-	}
-	if !same {
-		return reason, false
-	}
-	for i := range c1 {
-		if reason, same = compare(c1[i], c2[i]); !same {
-			return reason, same
-		}
-	}
-	return "", true
-}
-
 func trimAndSplitExplainResult(explainResult string) ([]string, error) {
 	lines := strings.Split(explainResult, "\n")
 	var idx [3]int
@@ -112,11 +75,23 @@ func matchVersion(version string) string {
 		return V3
 	} else if strings.Contains(v, "v4") {
 		return V4
+	} else if strings.Contains(v, "v5") {
+		return V5
+	} else if strings.Contains(v, "v6") {
+		return V6
+	} else if strings.Contains(v, "v7") {
+		return V7
 	}
 	return VUnknown
 }
 
 func identifyVersion(header string) string {
+	if strings.Contains(header, "access object") {
+		// TiDB 5.0 split the old free-form "operator info" column into a
+		// dedicated "access object" column plus a narrower "operator info";
+		// v6/v7 haven't touched this header, so they're identified as v5 too.
+		return V5
+	}
 	if strings.Contains(header, "estRows") {
 		return V4
 	}
@@ -166,16 +141,44 @@ func extractOperatorID(field string) string {
 
 func splitKVs(kvStr string) map[string]string {
 	kvMap := make(map[string]string)
-	kvs := strings.Split(kvStr, ",")
-	for _, kv := range kvs {
-		fields := strings.Split(kv, ":")
-		if len(fields) == 2 {
-			kvMap[strings.TrimSpace(fields[0])] = strings.TrimSpace(fields[1])
+	for _, kv := range splitTopLevel(kvStr, ',') {
+		idx := strings.Index(kv, ":")
+		if idx == -1 {
+			continue
 		}
+		key := strings.TrimSpace(kv[:idx])
+		val := strings.TrimSpace(kv[idx+1:])
+		kvMap[key] = val
 	}
 	return kvMap
 }
 
+// splitTopLevel splits s on sep, ignoring any sep found inside matching
+// parentheses, so e.g. "index:idx(a, b)" isn't split on the comma inside
+// idx(a, b).
+func splitTopLevel(s string, sep rune) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, s[start:])
+	return parts
+}
+
 func parseTaskType(taskStr string) TaskType {
 	task := strings.TrimSpace(strings.ToLower(taskStr))
 	if task == "root" {
@@ -189,6 +192,15 @@ func parseTaskType(taskStr string) TaskType {
 
 func MatchOpType(opID string) OpType {
 	x := strings.ToLower(opID)
+	if strings.Contains(x, "partitionunion") {
+		return OpTypePartitionUnion
+	}
+	if strings.Contains(x, "exchangesender") {
+		return OpTypeExchangeSender
+	}
+	if strings.Contains(x, "exchangereceiver") {
+		return OpTypeExchangeReceiver
+	}
 	if strings.Contains(x, "join") {
 		if strings.Contains(x, "hash") {
 			return OpTypeHashJoin
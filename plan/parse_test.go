@@ -0,0 +1,72 @@
+package plan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitKVs(t *testing.T) {
+	cases := []struct {
+		in  string
+		out map[string]string
+	}{
+		{
+			in:  "table:t1",
+			out: map[string]string{"table": "t1"},
+		},
+		{
+			in:  "table:t1, index:idx(a)",
+			out: map[string]string{"table": "t1", "index": "idx(a)"},
+		},
+		{
+			// a composite index must not be split on the comma inside the
+			// parens that separates its columns.
+			in:  "table:t1, index:idx(a, b)",
+			out: map[string]string{"table": "t1", "index": "idx(a, b)"},
+		},
+	}
+	for _, c := range cases {
+		got := splitKVs(c.in)
+		if !reflect.DeepEqual(got, c.out) {
+			t.Errorf("splitKVs(%q) = %v, want %v", c.in, got, c.out)
+		}
+	}
+}
+
+func TestParseAccessObject(t *testing.T) {
+	obj := parseAccessObject("table:t1, index:idx(a, b)")
+	if obj.table != "t1" {
+		t.Errorf("table = %q, want t1", obj.table)
+	}
+	if obj.index != "idx(a, b)" {
+		t.Errorf("index = %q, want idx(a, b)", obj.index)
+	}
+}
+
+func TestParseV5(t *testing.T) {
+	rows := [][]string{
+		{"TableReader_5", "3.00", "root", "", "data:TableFullScan_4"},
+		{"└─TableFullScan_4", "3.00", "cop[tikv]", "table:t1", "keep order:false"},
+	}
+	p, err := ParseV5("select * from t1", rows)
+	if err != nil {
+		t.Fatalf("ParseV5 error: %v", err)
+	}
+	if p.Root.Type() != OpTypeTableReader {
+		t.Fatalf("root type = %v, want OpTypeTableReader", p.Root.Type())
+	}
+	children := p.Root.Children()
+	if len(children) != 1 {
+		t.Fatalf("root has %v children, want 1", len(children))
+	}
+	scan, ok := children[0].(TableScanOp)
+	if !ok {
+		t.Fatalf("child is %T, want TableScanOp", children[0])
+	}
+	if scan.Table != "t1" {
+		t.Errorf("scan.Table = %q, want t1", scan.Table)
+	}
+	if scan.Task() != TaskTypeTiKV {
+		t.Errorf("scan.Task() = %v, want TaskTypeTiKV", scan.Task())
+	}
+}
@@ -0,0 +1,98 @@
+package plan
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// ParseV5 parses the `EXPLAIN` rows produced by TiDB 5.0 through 7.x.
+//
+// Starting from 5.0, TiDB split the old free-form "operator info" column
+// into a dedicated "access object" column (table/partition/index being
+// accessed) and a narrower "operator info" column, and introduced MPP
+// operators (ExchangeSender/ExchangeReceiver) for TiFlash. The row layout is:
+//
+//	id | estRows | task | access object | operator info
+//
+// ParseV5 tolerates the layout gaining an "estCost" column (added in 6.x)
+// between estRows and task, since downstream callers only care about
+// estRows today.
+func ParseV5(sql string, rows [][]string) (Plan, error) {
+	if len(rows) == 0 {
+		return Plan{}, errors.Errorf("empty explain rows")
+	}
+	idColNo, estRowsColNo, taskColNo, accessObjColNo, opInfoColNo, err := v5ColumnLayout(len(rows[0]))
+	if err != nil {
+		return Plan{}, err
+	}
+	root, err := buildOperatorV5(rows, 0, idColNo, estRowsColNo, taskColNo, accessObjColNo, opInfoColNo)
+	if err != nil {
+		return Plan{}, err
+	}
+	return Plan{SQL: sql, Ver: V5, Root: root}, nil
+}
+
+// v5ColumnLayout returns the column numbers of id/estRows/task/access
+// object/operator info for a v5-v7 explain row, given its column count.
+func v5ColumnLayout(numCols int) (idColNo, estRowsColNo, taskColNo, accessObjColNo, opInfoColNo int, err error) {
+	switch numCols {
+	case 5: // id, estRows, task, access object, operator info
+		return 0, 1, 2, 3, 4, nil
+	case 6: // id, estRows, estCost, task, access object, operator info
+		return 0, 1, 3, 4, 5, nil
+	}
+	return 0, 0, 0, 0, 0, errors.Errorf("unexpected number of columns in explain row: %v", numCols)
+}
+
+func buildOperatorV5(rows [][]string, rowNo, idColNo, estRowsColNo, taskColNo, accessObjColNo, opInfoColNo int) (Operator, error) {
+	id := extractOperatorID(rows[rowNo][idColNo])
+	estRows, err := strconv.ParseFloat(strings.TrimSpace(rows[rowNo][estRowsColNo]), 64)
+	if err != nil {
+		return nil, errors.Errorf("parse estRows of %v error: %v", id, err)
+	}
+	task := parseTaskType(rows[rowNo][taskColNo])
+	accessObj := parseAccessObject(rows[rowNo][accessObjColNo])
+
+	childRowNos := findChildRowNo(rows, rowNo, idColNo)
+	children := make([]Operator, 0, len(childRowNos))
+	for _, childRowNo := range childRowNos {
+		child, err := buildOperatorV5(rows, childRowNo, idColNo, estRowsColNo, taskColNo, accessObjColNo, opInfoColNo)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	base := BaseOp{id: id, opType: MatchOpType(id), estRow: estRows, task: task, children: children}
+	return newOperatorV5(base, accessObj), nil
+}
+
+// accessObject holds the table/index being accessed, extracted from the
+// "access object" column, e.g. "table:t1, index:idx(a)".
+type accessObject struct {
+	table string
+	index string
+}
+
+func parseAccessObject(field string) accessObject {
+	kvs := splitKVs(field)
+	return accessObject{table: kvs["table"], index: kvs["index"]}
+}
+
+func newOperatorV5(base BaseOp, obj accessObject) Operator {
+	switch base.opType {
+	case OpTypeTableScan:
+		return TableScanOp{BaseOp: base, Table: obj.table}
+	case OpTypeIndexScan:
+		return IndexScanOp{BaseOp: base, Table: obj.table, Index: obj.index}
+	case OpTypePartitionUnion:
+		return PartitionUnionOp{BaseOp: base}
+	case OpTypeExchangeSender:
+		return ExchangeSenderOp{BaseOp: base}
+	case OpTypeExchangeReceiver:
+		return ExchangeReceiverOp{BaseOp: base}
+	}
+	return base
+}
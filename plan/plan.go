@@ -11,6 +11,9 @@ type PlanVer string
 const (
 	V3       = "v3"
 	V4       = "v4"
+	V5       = "v5"
+	V6       = "v6"
+	V7       = "v7"
 	VUnknown = "unknown"
 )
 
@@ -28,6 +31,10 @@ const (
 	OpTypeIndexReader
 	OpTypeIndexScan
 	OpTypeIndexLookup
+	OpTypePointGet
+	OpTypePartitionUnion
+	OpTypeExchangeSender
+	OpTypeExchangeReceiver
 )
 
 func OpTypeIsJoin(opType OpType) bool {
@@ -170,4 +177,20 @@ type SelectionOp struct {
 
 type ProjectionOp struct {
 	BaseOp
-}
\ No newline at end of file
+}
+
+// PartitionUnionOp merges rows coming from several partitions of the same
+// partitioned table, e.g. `PartitionUnion_9` above a set of `TableScan`s.
+type PartitionUnionOp struct {
+	BaseOp
+}
+
+// ExchangeSenderOp and ExchangeReceiverOp appear in TiFlash MPP plans, where
+// one TiFlash node sends intermediate results to another over the network.
+type ExchangeSenderOp struct {
+	BaseOp
+}
+
+type ExchangeReceiverOp struct {
+	BaseOp
+}
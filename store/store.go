@@ -0,0 +1,221 @@
+// Package store persists captured plans and the plan-change events
+// detected between them, keyed by SQL digest.
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+)
+
+// Driver identifies which SQL backend a Store talks to. This mirrors the
+// driver-selection pattern used by lightning's checkpoint DB: callers pick a
+// Driver and DSN, and get back the same Store regardless of backend.
+type Driver string
+
+const (
+	DriverSQLite   Driver = "sqlite3"
+	DriverMySQL    Driver = "mysql"
+	DriverPostgres Driver = "postgres"
+)
+
+// PlanRecord is one captured plan for a SQL digest.
+type PlanRecord struct {
+	Fingerprint string
+	SQL         string
+	Plan        plan.Plan
+	Ver         string
+	CapturedAt  time.Time
+	TiDBVersion string
+}
+
+// PlanChangeEvent records that a digest's plan changed between two captures.
+type PlanChangeEvent struct {
+	Fingerprint string
+	OldPlan     plan.Plan
+	NewPlan     plan.Plan
+	Reason      string
+	DetectedAt  time.Time
+}
+
+// Store is a pluggable plan history backend: SQLite by default, with
+// MySQL/Postgres also supported.
+type Store interface {
+	// LatestPlan returns the most recently captured plan for fingerprint, or
+	// ok=false if none has been captured yet.
+	LatestPlan(fingerprint string) (rec PlanRecord, ok bool, err error)
+	// SavePlan persists a newly captured plan.
+	SavePlan(rec PlanRecord) error
+	// SaveChangeEvent records a detected plan-change event.
+	SaveChangeEvent(evt PlanChangeEvent) error
+	// ChangeEvents returns every recorded plan-change event, most recent first.
+	ChangeEvents() ([]PlanChangeEvent, error)
+	Close() error
+}
+
+// Open connects to a plan history store and ensures its schema exists. An
+// empty driver defaults to SQLite.
+func Open(driver Driver, dsn string) (Store, error) {
+	if driver == "" {
+		driver = DriverSQLite
+	}
+	db, err := sql.Open(string(driver), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %v store %v error: %v", driver, dsn, err)
+	}
+	s := &sqlStore{db: db, driver: driver}
+	if err := s.createSchema(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+type sqlStore struct {
+	db     *sql.DB
+	driver Driver
+}
+
+// rebind rewrites the `?` placeholders used throughout this file into the
+// positional `$1, $2, ...` placeholders Postgres requires; every other
+// driver's placeholder syntax is left untouched.
+func (s *sqlStore) rebind(query string) string {
+	if s.driver != DriverPostgres {
+		return query
+	}
+	var buf strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			buf.WriteByte('$')
+			buf.WriteString(strconv.Itoa(n))
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func (s *sqlStore) createSchema() error {
+	autoIncr := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	timeType := "DATETIME"
+	switch s.driver {
+	case DriverMySQL:
+		autoIncr = "INT AUTO_INCREMENT PRIMARY KEY"
+	case DriverPostgres:
+		autoIncr = "SERIAL PRIMARY KEY"
+		timeType = "TIMESTAMP"
+	}
+	stmts := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS captured_plans (
+			id %v,
+			fingerprint VARCHAR(64) NOT NULL,
+			sql TEXT NOT NULL,
+			plan TEXT NOT NULL,
+			ver VARCHAR(16) NOT NULL,
+			tidb_version VARCHAR(32) NOT NULL,
+			captured_at %v NOT NULL
+		)`, autoIncr, timeType),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS plan_change_events (
+			id %v,
+			fingerprint VARCHAR(64) NOT NULL,
+			old_plan TEXT NOT NULL,
+			new_plan TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			detected_at %v NOT NULL
+		)`, autoIncr, timeType),
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("create schema error: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) LatestPlan(fingerprint string) (PlanRecord, bool, error) {
+	row := s.db.QueryRow(s.rebind(`SELECT sql, plan, ver, tidb_version, captured_at FROM captured_plans
+		WHERE fingerprint = ? ORDER BY captured_at DESC LIMIT 1`), fingerprint)
+	var rec PlanRecord
+	var planData string
+	if err := row.Scan(&rec.SQL, &planData, &rec.Ver, &rec.TiDBVersion, &rec.CapturedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return PlanRecord{}, false, nil
+		}
+		return PlanRecord{}, false, fmt.Errorf("query latest plan for %v error: %v", fingerprint, err)
+	}
+	rec.Fingerprint = fingerprint
+	if err := json.Unmarshal([]byte(planData), &rec.Plan); err != nil {
+		return PlanRecord{}, false, fmt.Errorf("unmarshal plan for %v error: %v", fingerprint, err)
+	}
+	return rec, true, nil
+}
+
+func (s *sqlStore) SavePlan(rec PlanRecord) error {
+	data, err := json.Marshal(rec.Plan)
+	if err != nil {
+		return fmt.Errorf("marshal plan for %v error: %v", rec.Fingerprint, err)
+	}
+	_, err = s.db.Exec(s.rebind(`INSERT INTO captured_plans (fingerprint, sql, plan, ver, tidb_version, captured_at)
+		VALUES (?, ?, ?, ?, ?, ?)`), rec.Fingerprint, rec.SQL, string(data), rec.Ver, rec.TiDBVersion, rec.CapturedAt)
+	if err != nil {
+		return fmt.Errorf("save plan for %v error: %v", rec.Fingerprint, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) SaveChangeEvent(evt PlanChangeEvent) error {
+	oldData, err := json.Marshal(evt.OldPlan)
+	if err != nil {
+		return fmt.Errorf("marshal old plan for %v error: %v", evt.Fingerprint, err)
+	}
+	newData, err := json.Marshal(evt.NewPlan)
+	if err != nil {
+		return fmt.Errorf("marshal new plan for %v error: %v", evt.Fingerprint, err)
+	}
+	_, err = s.db.Exec(s.rebind(`INSERT INTO plan_change_events (fingerprint, old_plan, new_plan, reason, detected_at)
+		VALUES (?, ?, ?, ?, ?)`), evt.Fingerprint, string(oldData), string(newData), evt.Reason, evt.DetectedAt)
+	if err != nil {
+		return fmt.Errorf("save plan change event for %v error: %v", evt.Fingerprint, err)
+	}
+	return nil
+}
+
+func (s *sqlStore) ChangeEvents() ([]PlanChangeEvent, error) {
+	rows, err := s.db.Query(`SELECT fingerprint, old_plan, new_plan, reason, detected_at
+		FROM plan_change_events ORDER BY detected_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("query plan change events error: %v", err)
+	}
+	defer rows.Close()
+	var events []PlanChangeEvent
+	for rows.Next() {
+		var evt PlanChangeEvent
+		var oldData, newData string
+		if err := rows.Scan(&evt.Fingerprint, &oldData, &newData, &evt.Reason, &evt.DetectedAt); err != nil {
+			return nil, fmt.Errorf("scan plan change event error: %v", err)
+		}
+		if err := json.Unmarshal([]byte(oldData), &evt.OldPlan); err != nil {
+			return nil, fmt.Errorf("unmarshal old plan for %v error: %v", evt.Fingerprint, err)
+		}
+		if err := json.Unmarshal([]byte(newData), &evt.NewPlan); err != nil {
+			return nil, fmt.Errorf("unmarshal new plan for %v error: %v", evt.Fingerprint, err)
+		}
+		events = append(events, evt)
+	}
+	return events, rows.Err()
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qw4990/plan-change-capturer/plan"
+)
+
+func testPlan(sql string) plan.Plan {
+	p, err := plan.ParseV5(sql, [][]string{
+		{"TableReader_5", "3.00", "root", "", "data:TableFullScan_4"},
+		{"└─TableFullScan_4", "3.00", "cop[tikv]", "table:t1", "keep order:false"},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func TestSQLiteStorePlanRoundTrip(t *testing.T) {
+	st, err := Open(DriverSQLite, ":memory:")
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer st.Close()
+
+	if _, ok, err := st.LatestPlan("fp1"); err != nil || ok {
+		t.Fatalf("LatestPlan on empty store: ok=%v err=%v, want ok=false err=nil", ok, err)
+	}
+
+	rec := PlanRecord{
+		Fingerprint: "fp1",
+		SQL:         "select * from t1",
+		Plan:        testPlan("select * from t1"),
+		Ver:         string(plan.V5),
+		CapturedAt:  time.Now(),
+		TiDBVersion: "v7.0.0",
+	}
+	if err := st.SavePlan(rec); err != nil {
+		t.Fatalf("SavePlan error: %v", err)
+	}
+
+	got, ok, err := st.LatestPlan("fp1")
+	if err != nil || !ok {
+		t.Fatalf("LatestPlan after save: ok=%v err=%v, want ok=true err=nil", ok, err)
+	}
+	if got.SQL != rec.SQL || got.TiDBVersion != rec.TiDBVersion {
+		t.Errorf("LatestPlan = %+v, want SQL=%v TiDBVersion=%v", got, rec.SQL, rec.TiDBVersion)
+	}
+	if reason, same := plan.Compare(got.Plan, rec.Plan); !same {
+		t.Errorf("round-tripped plan differs from saved plan: %v", reason)
+	}
+}
+
+func TestSQLiteStoreChangeEvents(t *testing.T) {
+	st, err := Open(DriverSQLite, ":memory:")
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer st.Close()
+
+	evt := PlanChangeEvent{
+		Fingerprint: "fp1",
+		OldPlan:     testPlan("select * from t1"),
+		NewPlan:     testPlan("select * from t1"),
+		Reason:      "index changed",
+		DetectedAt:  time.Now(),
+	}
+	if err := st.SaveChangeEvent(evt); err != nil {
+		t.Fatalf("SaveChangeEvent error: %v", err)
+	}
+
+	events, err := st.ChangeEvents()
+	if err != nil {
+		t.Fatalf("ChangeEvents error: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("len(events) = %v, want 1", len(events))
+	}
+	if events[0].Fingerprint != evt.Fingerprint || events[0].Reason != evt.Reason {
+		t.Errorf("events[0] = %+v, want Fingerprint=%v Reason=%v", events[0], evt.Fingerprint, evt.Reason)
+	}
+}
+
+func TestRebindPostgresPlaceholders(t *testing.T) {
+	s := &sqlStore{driver: DriverPostgres}
+	got := s.rebind("SELECT sql FROM captured_plans WHERE fingerprint = ? AND ver = ?")
+	want := "SELECT sql FROM captured_plans WHERE fingerprint = $1 AND ver = $2"
+	if got != want {
+		t.Errorf("rebind() = %q, want %q", got, want)
+	}
+
+	// non-Postgres drivers must leave placeholders untouched.
+	s.driver = DriverSQLite
+	if got := s.rebind("WHERE fingerprint = ?"); got != "WHERE fingerprint = ?" {
+		t.Errorf("rebind() for sqlite driver = %q, want unchanged", got)
+	}
+}